@@ -2,12 +2,14 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/containers/common/pkg/capabilities"
@@ -65,6 +67,10 @@ type Config struct {
 	Engine EngineConfig `toml:"engine"`
 	// Network section defines the configuration of CNI Plugins
 	Network NetworkConfig `toml:"network"`
+
+	// setKeys records the keys explicitly set in the parsed config files,
+	// in their dotted TOML form (e.g. "engine.pull_policy").
+	setKeys map[string]bool
 }
 
 // ContainersConfig represents the "containers" TOML config table
@@ -213,6 +219,16 @@ type EngineConfig struct {
 	// this slice takes precedence.
 	HooksDir []string `toml:"hooks_dir"`
 
+	// ImageCopyTmpDir is the path to a directory used to store temporary
+	// files while pulling and saving images. If empty, the system default
+	// temporary directory is used.
+	ImageCopyTmpDir string `toml:"image_copy_tmp_dir,omitempty"`
+
+	// ImageDefaultSaveFormat is the default format used when saving images.
+	// Valid values are "docker-archive", "oci-archive", "docker-dir" and
+	// "oci-dir".
+	ImageDefaultSaveFormat string `toml:"image_default_save_format"`
+
 	// ImageDefaultTransport is the default transport method used to fetch
 	// images.
 	ImageDefaultTransport string `toml:"image_default_transport"`
@@ -254,9 +270,29 @@ type EngineConfig struct {
 	// OCIRuntimes are the set of configured OCI runtimes (default is runc).
 	OCIRuntimes map[string][]string `toml:"runtimes"`
 
+	// PreferOCIManifest indicates that images saved in a format able to
+	// carry either manifest type should use the OCI manifest instead of the
+	// Docker v2s2 manifest.
+	PreferOCIManifest bool `toml:"prefer_oci_manifest"`
+
 	// PullPolicy determines whether to pull image before creating or running a container
 	// default is "missing"
 	PullPolicy string `toml:"pull_policy"`
+
+	// RecordShortNameAliases indicates whether the resolution of a short
+	// name to a fully-qualified image reference is recorded as an alias
+	// after a successful pull. Per-pull options take precedence.
+	RecordShortNameAliases bool `toml:"record_short_name_aliases"`
+
+	// Retry is the number of times to retry failed image pulls and pushes.
+	// It must not exceed MaxRetry.
+	Retry uint `toml:"retry"`
+
+	// RetryDelay is the delay between retries of failed image pulls and
+	// pushes, expressed as a Go duration string (e.g. "2s"). If empty, the
+	// caller's default delay is used.
+	RetryDelay string `toml:"retry_delay,omitempty"`
+
 	// RuntimePath is the path to OCI runtime binary for launching containers.
 	// The first path pointing to a valid file will be used This is used only
 	// when there are no OCIRuntime/OCIRuntimes defined.  It is used only to be
@@ -429,27 +465,112 @@ func NewConfig(userConfigPath string) (*Config, error) {
 	return config, nil
 }
 
+// ConfigFile is a containers.conf file to be read by NewFromConfigFiles.
+type ConfigFile struct {
+	// Path is the path to the config file.
+	Path string
+	// Optional indicates that the config file is skipped if it does not
+	// exist, instead of causing an error.
+	Optional bool
+}
+
+// NewFromFiles creates a new Config from the hard-coded defaults and the
+// config files at `paths`. Unlike NewConfig, neither the system configs nor
+// libpod.conf are read; only the specified files are merged, in order, so
+// fields in later files override those in earlier ones. All files must exist;
+// use NewFromConfigFiles to mark some of them as optional.
+func NewFromFiles(paths []string) (*Config, error) {
+	files := make([]ConfigFile, 0, len(paths))
+	for _, path := range paths {
+		files = append(files, ConfigFile{Path: path})
+	}
+	return NewFromConfigFiles(files)
+}
+
+// NewFromConfigFiles creates a new Config like NewFromFiles. Files marked as
+// Optional are skipped if they do not exist, while a missing file which is not
+// optional causes an error.
+func NewFromConfigFiles(files []ConfigFile) (*Config, error) {
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		if file.Optional {
+			if _, err := os.Stat(file.Path); os.IsNotExist(err) {
+				logrus.Debugf("Skipping optional config %q: %v", file.Path, err)
+				continue
+			}
+		}
+		paths = append(paths, file.Path)
+	}
+	return newConfigFromPaths(paths, readConfigFromFile)
+}
+
+// newConfigFromPaths creates a new Config from the hard-coded defaults and
+// merges the configs at `paths` in order, using `read` to read each of them.
+func newConfigFromPaths(paths []string, read func(path string, config *Config) (*Config, error)) (*Config, error) {
+	config, err := DefaultConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		config, err = read(path, config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading config %q", path)
+		}
+		logrus.Debugf("Merged config %q: %v", path, config)
+	}
+	config.addCAPPrefix()
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
 // readConfigFromFile reads the specified config file at `path` and attempts to
 // unmarshal its content into a Config. The config param specifies the previous
 // default config. If the path, only specifies a few fields in the Toml file
 // the defaults from the config parameter will be used for all other fields.
 func readConfigFromFile(path string, config *Config) (*Config, error) {
 	logrus.Debugf("Reading configuration file %q", path)
-	_, err := toml.DecodeFile(path, config)
+	meta, err := toml.DecodeFile(path, config)
 	if err != nil {
 		return nil, fmt.Errorf("unable to decode configuration %v: %v", path, err)
 	}
-	if config.Engine.VolumePath != "" {
-		config.Engine.VolumePathSet = true
+	config.updateFromMetaData(meta)
+
+	return config, err
+}
+
+// updateFromMetaData records the keys defined in a just-decoded config file
+// and marks the options in SetOptions which have been set.
+func (c *Config) updateFromMetaData(meta toml.MetaData) {
+	if c.setKeys == nil {
+		c.setKeys = make(map[string]bool)
+	}
+	for _, key := range meta.Keys() {
+		c.setKeys[key.String()] = true
+	}
+	if c.Engine.VolumePath != "" {
+		c.Engine.VolumePathSet = true
 	}
-	if config.Engine.StaticDir != "" {
-		config.Engine.StaticDirSet = true
+	if c.Engine.StaticDir != "" {
+		c.Engine.StaticDirSet = true
 	}
-	if config.Engine.TmpDir != "" {
-		config.Engine.TmpDirSet = true
+	if c.Engine.TmpDir != "" {
+		c.Engine.TmpDirSet = true
 	}
+}
 
-	return config, err
+// IsSet returns true if the option at `key` has been explicitly set in any of
+// the parsed config files, even if it was set to its zero value. The key uses
+// the dotted TOML form of the containers.conf option, for example
+// "engine.pull_policy" or "containers.pids_limit". Options set in the
+// deprecated libpod.conf are reported under their containers.conf key; those
+// without a containers.conf equivalent are not recorded.
+func (c *Config) IsSet(key string) bool {
+	return c.setKeys[key]
 }
 
 // Returns the list of configuration files, if they exist in order of hierarchy.
@@ -556,6 +677,9 @@ func (c *EngineConfig) Validate() error {
 	if c.VolumePath != "" && !filepath.IsAbs(c.VolumePath) {
 		return fmt.Errorf("volume path must be an absolute path - instead got %q", c.VolumePath)
 	}
+	if c.ImageCopyTmpDir != "" && !filepath.IsAbs(c.ImageCopyTmpDir) {
+		return fmt.Errorf("image copy temporary directory must be an absolute path - instead got %q", c.ImageCopyTmpDir)
+	}
 
 	// Check if the pullPolicy from containers.conf is valid
 	// if it is invalid returns the error
@@ -563,9 +687,48 @@ func (c *EngineConfig) Validate() error {
 	if _, err := ValidatePullPolicy(pullPolicy); err != nil {
 		return errors.Wrapf(err, "invalid pull type from containers.conf %q", c.PullPolicy)
 	}
+
+	if err := ValidateImageSaveFormat(c.ImageDefaultSaveFormat); err != nil {
+		return errors.Wrapf(err, "invalid image_default_save_format from containers.conf")
+	}
+
+	if c.Retry > MaxRetry {
+		return errors.Errorf("invalid retry %d: must not be greater than %d", c.Retry, MaxRetry)
+	}
+
+	if _, err := c.ParsedRetryDelay(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// EffectiveMaxRetries returns the number of times to retry failed image pulls
+// and pushes, capped at MaxRetry. Explicit per-operation settings in the
+// calling tool take precedence over this value.
+func (c *EngineConfig) EffectiveMaxRetries() uint {
+	if c.Retry > MaxRetry {
+		return MaxRetry
+	}
+	return c.Retry
+}
+
+// ParsedRetryDelay returns the RetryDelay as a time.Duration. A zero duration
+// is returned if RetryDelay is empty, in which case callers should fall back
+// to their own default delay.
+func (c *EngineConfig) ParsedRetryDelay() (time.Duration, error) {
+	if c.RetryDelay == "" {
+		return 0, nil
+	}
+	delay, err := time.ParseDuration(c.RetryDelay)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid retry_delay %q", c.RetryDelay)
+	}
+	if delay < 0 {
+		return 0, errors.Errorf("invalid retry_delay %q: must not be negative", c.RetryDelay)
+	}
+	return delay, nil
+}
+
 // Validate is the main entry point for containers configuration validation
 // It returns an `error` on validation failure, otherwise
 // `nil`.
@@ -642,6 +805,64 @@ func ValidatePullPolicy(pullPolicy string) (PullPolicy, error) {
 	}
 }
 
+// ValidateImageSaveFormat checks if the image save format from CLI or
+// containers.conf is one of the supported formats. An empty format is valid
+// and selects DefaultImageSaveFormat.
+func ValidateImageSaveFormat(format string) error {
+	switch format {
+	case "", ImageSaveFormatDockerArchive, ImageSaveFormatOCIArchive, ImageSaveFormatDockerDir, ImageSaveFormatOCIDir:
+		return nil
+	default:
+		return errors.Errorf("invalid image save format %q", format)
+	}
+}
+
+// ImageSaveManifestType returns the manifest MIME type to use when saving
+// images in the specified format. Formats that can only carry one manifest
+// type always use it; docker-archive in particular cannot hold an OCI
+// manifest. For docker-dir, PreferOCIManifest selects the OCI manifest.
+func (c *Config) ImageSaveManifestType(format string) (string, error) {
+	if format == "" {
+		format = c.ImageSaveFormat()
+	}
+	switch format {
+	case ImageSaveFormatDockerArchive:
+		return ManifestTypeDockerV2Schema2, nil
+	case ImageSaveFormatDockerDir:
+		if c.Engine.PreferOCIManifest {
+			return ManifestTypeOCI, nil
+		}
+		return ManifestTypeDockerV2Schema2, nil
+	case ImageSaveFormatOCIArchive, ImageSaveFormatOCIDir:
+		return ManifestTypeOCI, nil
+	default:
+		return "", errors.Errorf("invalid image save format %q", format)
+	}
+}
+
+// ImageCopyTmpDir returns the directory to use for temporary files while
+// pulling and saving images. It is the configured image_copy_tmp_dir or, if
+// unset, the system default temporary directory. An error is returned if the
+// directory is not writable.
+func (c *Config) ImageCopyTmpDir() (string, error) {
+	dir := c.Engine.ImageCopyTmpDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := isDirectory(dir); err != nil {
+		return "", errors.Wrapf(err, "invalid image copy temporary directory %q", dir)
+	}
+	f, err := ioutil.TempFile(dir, ".image-copy-tmp-check")
+	if err != nil {
+		return "", errors.Wrapf(err, "image copy temporary directory %q is not writable", dir)
+	}
+	f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		logrus.Debugf("unable to remove %s: %v", f.Name(), err)
+	}
+	return dir, nil
+}
+
 // FindConmon iterates over (*Config).ConmonPath and returns the path
 // to first (version) matching conmon binary. If non is found, we try
 // to do a path lookup of "conmon".
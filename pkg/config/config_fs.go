@@ -0,0 +1,38 @@
+// +build go1.16
+
+package config
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sirupsen/logrus"
+)
+
+// NewFromFS creates a new Config from the hard-coded defaults and the config
+// files at `paths` in `fsys`, for example an embed.FS. It behaves like
+// NewFromFiles, except that the files are read from `fsys` instead of the OS
+// filesystem.
+func NewFromFS(fsys fs.FS, paths []string) (*Config, error) {
+	return newConfigFromPaths(paths, func(path string, config *Config) (*Config, error) {
+		return readConfigFromFS(fsys, path, config)
+	})
+}
+
+// readConfigFromFS reads the config file at `path` in `fsys` and merges it
+// into config, see readConfigFromFile.
+func readConfigFromFS(fsys fs.FS, path string, config *Config) (*Config, error) {
+	logrus.Debugf("Reading configuration file %q", path)
+	data, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := toml.Decode(string(data), config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode configuration %v: %v", path, err)
+	}
+	config.updateFromMetaData(meta)
+
+	return config, nil
+}
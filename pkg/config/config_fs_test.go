@@ -0,0 +1,53 @@
+// +build go1.16
+
+package config
+
+import (
+	"testing/fstest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewFromFS", func() {
+	fsys := fstest.MapFS{
+		"base.conf": &fstest.MapFile{
+			Data: []byte("[containers]\napparmor_profile = \"base\"\npids_limit = 1024\n"),
+		},
+		"override.conf": &fstest.MapFile{
+			Data: []byte("[containers]\napparmor_profile = \"overridden\"\n"),
+		},
+		"invalid.conf": &fstest.MapFile{
+			Data: []byte("[engine]\npull_policy = \"sometimes\"\n"),
+		},
+	}
+
+	It("should merge files in order", func() {
+		// Given
+		// When
+		config, err := NewFromFS(fsys, []string{"base.conf", "override.conf"})
+		// Then
+		Expect(err).To(BeNil())
+		Expect(config.Containers.ApparmorProfile).To(Equal("overridden"))
+		Expect(config.Containers.PidsLimit).To(BeEquivalentTo(1024))
+		Expect(config.IsSet("containers.pids_limit")).To(BeTrue())
+	})
+
+	It("should fail with missing file", func() {
+		// Given
+		// When
+		config, err := NewFromFS(fsys, []string{"base.conf", "missing.conf"})
+		// Then
+		Expect(err).ToNot(BeNil())
+		Expect(config).To(BeNil())
+	})
+
+	It("should fail with invalid value", func() {
+		// Given
+		// When
+		config, err := NewFromFS(fsys, []string{"invalid.conf"})
+		// Then
+		Expect(err).ToNot(BeNil())
+		Expect(config).To(BeNil())
+	})
+})
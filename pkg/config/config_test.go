@@ -6,6 +6,7 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/containers/common/pkg/capabilities"
 	. "github.com/onsi/ginkgo"
@@ -323,6 +324,59 @@ var _ = Describe("Config", func() {
 			Expect(err).NotTo(BeNil())
 		})
 
+		It("should record explicitly set keys", func() {
+			// Given
+			tmpfile, err := ioutil.TempFile("", "containers-conf")
+			Expect(err).To(BeNil())
+			defer os.Remove(tmpfile.Name())
+			_, err = tmpfile.WriteString("[engine]\nstop_timeout = 0\n")
+			Expect(err).To(BeNil())
+			tmpfile.Close()
+
+			// When
+			conf, _ := DefaultConfig()
+			conf, err = readConfigFromFile(tmpfile.Name(), conf)
+
+			// Then
+			Expect(err).To(BeNil())
+			Expect(conf.Engine.StopTimeout).To(BeEquivalentTo(0))
+			Expect(conf.IsSet("engine.stop_timeout")).To(BeTrue())
+			Expect(conf.IsSet("engine.num_locks")).To(BeFalse())
+			Expect(conf.IsSet("containers.apparmor_profile")).To(BeFalse())
+
+			// When
+			conf, err = readConfigFromFile("testdata/containers_override.conf", conf)
+
+			// Then
+			Expect(err).To(BeNil())
+			Expect(conf.IsSet("engine.stop_timeout")).To(BeTrue())
+			Expect(conf.IsSet("containers.apparmor_profile")).To(BeTrue())
+		})
+
+		It("should record explicitly set keys from libpod.conf", func() {
+			// Given
+			tmpfile, err := ioutil.TempFile("", "libpod-conf")
+			Expect(err).To(BeNil())
+			defer os.Remove(tmpfile.Name())
+			_, err = tmpfile.WriteString("num_locks = 0\ncni_config_dir = \"/etc/cni/net.d/\"\n[runtimes]\nrunc = [\"/usr/bin/runc\"]\n")
+			Expect(err).To(BeNil())
+			tmpfile.Close()
+
+			// When
+			conf, _ := DefaultConfig()
+			libpodConf, err := readLibpodConfigFromFile(tmpfile.Name(), conf.libpodConfig())
+			Expect(err).To(BeNil())
+			conf.libpodToContainersConfig(libpodConf)
+
+			// Then
+			Expect(conf.Engine.NumLocks).To(BeEquivalentTo(0))
+			Expect(conf.IsSet("engine.num_locks")).To(BeTrue())
+			Expect(conf.IsSet("network.network_config_dir")).To(BeTrue())
+			Expect(conf.IsSet("engine.runtimes")).To(BeTrue())
+			Expect(conf.IsSet("engine.runtimes.runc")).To(BeTrue())
+			Expect(conf.IsSet("engine.lock_type")).To(BeFalse())
+		})
+
 		It("should fail when toml decode fails", func() {
 			// Given
 			// When
@@ -443,6 +497,61 @@ var _ = Describe("Config", func() {
 			Expect(config).To(BeNil())
 		})
 
+		It("should merge files passed to NewFromFiles in order", func() {
+			// Given
+			// When
+			config, err := NewFromFiles([]string{
+				"testdata/containers_default.conf",
+				"testdata/containers_override.conf",
+			})
+			// Then
+			Expect(err).To(BeNil())
+			Expect(config.Containers.ApparmorProfile).To(Equal("overridden-default"))
+			Expect(config.Containers.PidsLimit).To(BeEquivalentTo(2048))
+
+			// When
+			config, err = NewFromFiles([]string{
+				"testdata/containers_override.conf",
+				"testdata/containers_default.conf",
+			})
+			// Then
+			Expect(err).To(BeNil())
+			Expect(config.Containers.ApparmorProfile).To(Equal("container-default"))
+		})
+
+		It("should fail NewFromFiles with missing file", func() {
+			// Given
+			// When
+			config, err := NewFromFiles([]string{
+				"testdata/containers_default.conf",
+				"/invalid/file",
+			})
+			// Then
+			Expect(err).ToNot(BeNil())
+			Expect(config).To(BeNil())
+		})
+
+		It("should skip optional missing file in NewFromConfigFiles", func() {
+			// Given
+			// When
+			config, err := NewFromConfigFiles([]ConfigFile{
+				{Path: "testdata/containers_override.conf"},
+				{Path: "/invalid/file", Optional: true},
+			})
+			// Then
+			Expect(err).To(BeNil())
+			Expect(config.Containers.ApparmorProfile).To(Equal("overridden-default"))
+
+			// When
+			config, err = NewFromConfigFiles([]ConfigFile{
+				{Path: "testdata/containers_override.conf", Optional: true},
+				{Path: "/invalid/file"},
+			})
+			// Then
+			Expect(err).ToNot(BeNil())
+			Expect(config).To(BeNil())
+		})
+
 		It("Test Capabilities call", func() {
 			// Given
 			// When
@@ -508,5 +617,142 @@ var _ = Describe("Config", func() {
 			err := sut.Engine.Validate()
 			Expect(err).ToNot(BeNil())
 		})
+
+		It("should succeed with default image_default_save_format", func() {
+			err := sut.Engine.Validate()
+			Expect(err).To(BeNil())
+			Expect(sut.ImageSaveFormat()).To(Equal(DefaultImageSaveFormat))
+
+			sut.Engine.ImageDefaultSaveFormat = "oci-archive"
+			err = sut.Engine.Validate()
+			Expect(err).To(BeNil())
+			Expect(sut.ImageSaveFormat()).To(Equal("oci-archive"))
+
+			sut.Engine.ImageDefaultSaveFormat = ""
+			err = sut.Engine.Validate()
+			Expect(err).To(BeNil())
+			Expect(sut.ImageSaveFormat()).To(Equal(DefaultImageSaveFormat))
+		})
+
+		It("should fail with invalid image_default_save_format", func() {
+			sut.Engine.ImageDefaultSaveFormat = "tarball"
+			err := sut.Engine.Validate()
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("verify ImageSaveManifestType", func() {
+			mimeType, err := sut.ImageSaveManifestType("")
+			Expect(err).To(BeNil())
+			Expect(mimeType).To(Equal(ManifestTypeDockerV2Schema2))
+
+			mimeType, err = sut.ImageSaveManifestType("oci-dir")
+			Expect(err).To(BeNil())
+			Expect(mimeType).To(Equal(ManifestTypeOCI))
+
+			mimeType, err = sut.ImageSaveManifestType("docker-dir")
+			Expect(err).To(BeNil())
+			Expect(mimeType).To(Equal(ManifestTypeDockerV2Schema2))
+
+			sut.Engine.PreferOCIManifest = true
+			mimeType, err = sut.ImageSaveManifestType("docker-dir")
+			Expect(err).To(BeNil())
+			Expect(mimeType).To(Equal(ManifestTypeOCI))
+
+			// docker-archive cannot carry an OCI manifest
+			mimeType, err = sut.ImageSaveManifestType("docker-archive")
+			Expect(err).To(BeNil())
+			Expect(mimeType).To(Equal(ManifestTypeDockerV2Schema2))
+
+			_, err = sut.ImageSaveManifestType("tarball")
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("verify ImageCopyTmpDir", func() {
+			validDirPath, err := ioutil.TempDir("", "config-copy-tmp")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(validDirPath)
+
+			dir, err := sut.ImageCopyTmpDir()
+			Expect(err).To(BeNil())
+			Expect(dir).To(Equal(os.TempDir()))
+
+			sut.Engine.ImageCopyTmpDir = validDirPath
+			Expect(sut.Engine.Validate()).To(BeNil())
+			dir, err = sut.ImageCopyTmpDir()
+			Expect(err).To(BeNil())
+			Expect(dir).To(Equal(validDirPath))
+
+			sut.Engine.ImageCopyTmpDir = invalidPath
+			_, err = sut.ImageCopyTmpDir()
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("should fail with relative image_copy_tmp_dir", func() {
+			sut.Engine.ImageCopyTmpDir = "var/tmp"
+			err := sut.Engine.Validate()
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("should record short-name aliases by default", func() {
+			Expect(sut.Engine.RecordShortNameAliases).To(BeTrue())
+
+			config, err := NewFromFiles([]string{"testdata/containers_default.conf"})
+			Expect(err).To(BeNil())
+			Expect(config.Engine.RecordShortNameAliases).To(BeTrue())
+
+			tmpfile, err := ioutil.TempFile("", "containers-conf")
+			Expect(err).To(BeNil())
+			defer os.Remove(tmpfile.Name())
+			_, err = tmpfile.WriteString("[engine]\nrecord_short_name_aliases = false\n")
+			Expect(err).To(BeNil())
+			tmpfile.Close()
+
+			config, err = NewFromFiles([]string{tmpfile.Name()})
+			Expect(err).To(BeNil())
+			Expect(config.Engine.RecordShortNameAliases).To(BeFalse())
+		})
+
+		It("should succeed with valid retry", func() {
+			Expect(sut.Engine.Validate()).To(BeNil())
+			Expect(sut.Engine.EffectiveMaxRetries()).To(BeEquivalentTo(DefaultRetry))
+
+			sut.Engine.Retry = 0
+			Expect(sut.Engine.Validate()).To(BeNil())
+			Expect(sut.Engine.EffectiveMaxRetries()).To(BeEquivalentTo(0))
+
+			sut.Engine.Retry = MaxRetry
+			Expect(sut.Engine.Validate()).To(BeNil())
+			Expect(sut.Engine.EffectiveMaxRetries()).To(BeEquivalentTo(MaxRetry))
+		})
+
+		It("should fail with too large retry", func() {
+			sut.Engine.Retry = 1000000
+			err := sut.Engine.Validate()
+			Expect(err).ToNot(BeNil())
+			Expect(sut.Engine.EffectiveMaxRetries()).To(BeEquivalentTo(MaxRetry))
+		})
+
+		It("should succeed with valid retry_delay", func() {
+			delay, err := sut.Engine.ParsedRetryDelay()
+			Expect(err).To(BeNil())
+			Expect(delay).To(BeEquivalentTo(0))
+
+			sut.Engine.RetryDelay = "2s"
+			err = sut.Engine.Validate()
+			Expect(err).To(BeNil())
+			delay, err = sut.Engine.ParsedRetryDelay()
+			Expect(err).To(BeNil())
+			Expect(delay).To(Equal(2 * time.Second))
+		})
+
+		It("should fail with invalid retry_delay", func() {
+			sut.Engine.RetryDelay = "2 seconds"
+			err := sut.Engine.Validate()
+			Expect(err).ToNot(BeNil())
+
+			sut.Engine.RetryDelay = "-1s"
+			err = sut.Engine.Validate()
+			Expect(err).ToNot(BeNil())
+		})
 	})
 })
@@ -105,6 +105,27 @@ const (
 	DefaultPidsLimit = 2048
 	// DefaultPullPolicy pulls the image if it does not exist locally
 	DefaultPullPolicy = "missing"
+	// DefaultRetry is the default number of times to retry failed image
+	// pulls and pushes
+	DefaultRetry = 3
+	// MaxRetry is the maximum allowed number of times to retry failed image
+	// pulls and pushes
+	MaxRetry = 100
+	// ImageSaveFormatDockerArchive saves images as a docker-archive tarball
+	ImageSaveFormatDockerArchive = "docker-archive"
+	// ImageSaveFormatOCIArchive saves images as an oci-archive tarball
+	ImageSaveFormatOCIArchive = "oci-archive"
+	// ImageSaveFormatDockerDir saves images to a directory using the
+	// containers/image dir layout
+	ImageSaveFormatDockerDir = "docker-dir"
+	// ImageSaveFormatOCIDir saves images to an OCI layout directory
+	ImageSaveFormatOCIDir = "oci-dir"
+	// DefaultImageSaveFormat is the default format used when saving images
+	DefaultImageSaveFormat = ImageSaveFormatDockerArchive
+	// ManifestTypeDockerV2Schema2 is the MIME type of a Docker v2s2 manifest
+	ManifestTypeDockerV2Schema2 = "application/vnd.docker.distribution.manifest.v2+json"
+	// ManifestTypeOCI is the MIME type of an OCI image manifest
+	ManifestTypeOCI = "application/vnd.oci.image.manifest.v1+json"
 	// DefaultRootlessSignaturePolicyPath is the default value for the
 	// rootless policy.json file.
 	DefaultRootlessSignaturePolicyPath = ".config/containers/policy.json"
@@ -208,6 +229,7 @@ func defaultConfigFromMemory() (*EngineConfig, error) {
 	c.VolumePath = filepath.Join(storeOpts.GraphRoot, "volumes")
 
 	c.HooksDir = DefaultHooksDirs
+	c.ImageDefaultSaveFormat = DefaultImageSaveFormat
 	c.ImageDefaultTransport = _defaultTransport
 	c.StateType = BoltDBStateStore
 
@@ -264,6 +286,8 @@ func defaultConfigFromMemory() (*EngineConfig, error) {
 		"/run/current-system/sw/bin/conmon",
 	}
 	c.PullPolicy = DefaultPullPolicy
+	c.RecordShortNameAliases = true
+	c.Retry = DefaultRetry
 	c.RuntimeSupportsJSON = []string{
 		"crun",
 		"runc",
@@ -469,3 +493,11 @@ func (c *Config) PidsLimit() int64 {
 func (c *Config) DetachKeys() string {
 	return c.Engine.DetachKeys
 }
+
+// ImageSaveFormat returns the default format to use when saving images
+func (c *Config) ImageSaveFormat() string {
+	if c.Engine.ImageDefaultSaveFormat == "" {
+		return DefaultImageSaveFormat
+	}
+	return c.Engine.ImageDefaultSaveFormat
+}
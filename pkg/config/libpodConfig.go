@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/containers/common/pkg/cgroupv2"
@@ -178,6 +179,48 @@ type ConfigFromLibpod struct {
 	// CgroupCheck indicates the configuration has been rewritten after an
 	// upgrade to Fedora 31 to change the default OCI runtime for cgroupv2v2.
 	CgroupCheck bool `toml:"cgroup_check,omitempty"`
+
+	// setKeys records the keys explicitly set in the parsed libpod.conf
+	// files.
+	setKeys map[string]bool
+}
+
+// libpodConfigKeys maps the libpod.conf keys to the corresponding
+// containers.conf keys, in their dotted TOML form. Keys without a
+// containers.conf equivalent are not listed.
+var libpodConfigKeys = map[string]string{
+	"init_path":                   "containers.init_path",
+	"max_log_size":                "containers.log_size_max",
+	"label":                       "containers.label",
+	"volume_path":                 "engine.volume_path",
+	"image_default_transport":     "engine.image_default_transport",
+	"runtime":                     "engine.runtime",
+	"runtimes":                    "engine.runtimes",
+	"runtime_supports_json":       "engine.runtime_supports_json",
+	"runtime_supports_nocgroupv2": "engine.runtime_supports_nocgroupv2",
+	"runtime_path":                "engine.runtime_path",
+	"conmon_path":                 "engine.conmon_path",
+	"conmon_env_vars":             "engine.conmon_env_vars",
+	"cgroup_manager":              "engine.cgroup_manager",
+	"static_dir":                  "engine.static_dir",
+	"tmp_dir":                     "engine.tmp_dir",
+	"no_pivot_root":               "engine.no_pivot_root",
+	"hooks_dir":                   "engine.hooks_dir",
+	"namespace":                   "engine.namespace",
+	"infra_image":                 "engine.infra_image",
+	"infra_command":               "engine.infra_command",
+	"enable_port_reservation":     "engine.enable_port_reservation",
+	"network_cmd_path":            "engine.network_cmd_path",
+	"num_locks":                   "engine.num_locks",
+	"lock_type":                   "engine.lock_type",
+	"events_logger":               "engine.events_logger",
+	"events_logfile_path":         "engine.events_logfile_path",
+	"detach_keys":                 "engine.detach_keys",
+	"SDNotify":                    "engine.SDNotify",
+	"cgroup_check":                "engine.cgroup_check",
+	"cni_config_dir":              "network.network_config_dir",
+	"cni_plugin_dir":              "network.cni_plugin_dirs",
+	"cni_default_network":         "network.default_network",
 }
 
 // newLibpodConfig creates a new ConfigFromLibpod and converts it to Config.
@@ -235,10 +278,16 @@ func newLibpodConfig(c *Config) error {
 // the defaults from the config parameter will be used for all other fields.
 func readLibpodConfigFromFile(path string, config *ConfigFromLibpod) (*ConfigFromLibpod, error) {
 	logrus.Debugf("Reading configuration file %q", path)
-	_, err := toml.DecodeFile(path, config)
+	meta, err := toml.DecodeFile(path, config)
 	if err != nil {
 		return nil, fmt.Errorf("unable to decode configuration %v: %v", path, err)
 	}
+	if config.setKeys == nil {
+		config.setKeys = make(map[string]bool)
+	}
+	for _, key := range meta.Keys() {
+		config.setKeys[key.String()] = true
+	}
 
 	// For the sake of backwards compat we need to check if the config fields
 	// with *Set suffix are set in the config.  Note that the storage-related
@@ -380,4 +429,21 @@ func (c *Config) libpodToContainersConfig(libpodConf *ConfigFromLibpod) {
 	c.Network.NetworkConfigDir = libpodConf.CNIConfigDir
 	c.Network.CNIPluginDirs = libpodConf.CNIPluginDir
 	c.Network.DefaultNetwork = libpodConf.CNIDefaultNetwork
+
+	for key := range libpodConf.setKeys {
+		// Tables such as runtimes are recorded along with their keys, e.g.
+		// "runtimes.runc", so map the first component of the key.
+		parts := strings.SplitN(key, ".", 2)
+		newKey, ok := libpodConfigKeys[parts[0]]
+		if !ok {
+			continue
+		}
+		if len(parts) == 2 {
+			newKey += "." + parts[1]
+		}
+		if c.setKeys == nil {
+			c.setKeys = make(map[string]bool)
+		}
+		c.setKeys[newKey] = true
+	}
 }